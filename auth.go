@@ -0,0 +1,39 @@
+package mongodb
+
+import "gopkg.in/mgo.v2"
+
+// Auth describes a non-x509 login to perform after dialing. Mechanism
+// selects one of the mechanisms supported by mgo: "MONGODB-CR",
+// "SCRAM-SHA-1", "SCRAM-SHA-256" or "GSSAPI". Leaving Mechanism empty keeps
+// the existing x509 client-certificate login behavior.
+type Auth struct {
+	// Mechanism is one of "MONGODB-CR", "SCRAM-SHA-1", "SCRAM-SHA-256" or
+	// "GSSAPI". mgo only implements SCRAM-SHA-1 natively; SCRAM-SHA-256 and
+	// GSSAPI both go through mgo's cgo-based SASL bindings, so both require
+	// building with the mongodb_gssapi tag (and mgo's own "sasl" tag, which
+	// this package cannot forward on a caller's behalf).
+	Mechanism string
+	// Source is the database the credentials are defined in, e.g. "admin"
+	// or "$external" for GSSAPI/Kerberos.
+	Source string
+	// Username and Password authenticate MONGODB-CR and SCRAM-SHA
+	// mechanisms, and may also carry the Kerberos principal for GSSAPI.
+	Username string
+	Password string
+	// Service and ServiceHost name the Kerberos service principal for
+	// GSSAPI, e.g. Service "mongodb" and ServiceHost the server's
+	// canonical name.
+	Service     string
+	ServiceHost string
+}
+
+func (a Auth) credential() *mgo.Credential {
+	return &mgo.Credential{
+		Username:    a.Username,
+		Password:    a.Password,
+		Source:      a.Source,
+		Service:     a.Service,
+		ServiceHost: a.ServiceHost,
+		Mechanism:   a.Mechanism,
+	}
+}
@@ -0,0 +1,10 @@
+// +build mongodb_gssapi,sasl
+
+package mongodb
+
+// gssapiEnabled reports that this binary was built with both the
+// mongodb_gssapi tag and mgo's own "sasl" tag. gopkg.in/mgo.v2 stubs out
+// saslNew, the function GSSAPI and SCRAM-SHA-256 both rely on, unless the
+// whole binary is built with -tags sasl; mongodb_gssapi alone cannot force
+// that on a dependent binary, so callers must pass both tags themselves.
+const gssapiEnabled = true
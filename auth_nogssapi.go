@@ -0,0 +1,9 @@
+// +build !mongodb_gssapi !sasl
+
+package mongodb
+
+// gssapiEnabled is false unless this binary is built with both the
+// mongodb_gssapi tag and mgo's own "sasl" tag (gopkg.in/mgo.v2 stubs out
+// saslNew, the function GSSAPI and SCRAM-SHA-256 both rely on, unless the
+// whole binary is built with -tags sasl).
+const gssapiEnabled = false
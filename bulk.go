@@ -0,0 +1,109 @@
+package mongodb
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+)
+
+// BufferedBulk batches Insert, Upsert and Remove operations on a single
+// collection and flushes them via mgo.Bulk once size operations have
+// accumulated, giving large import/backfill jobs a significant throughput
+// win over a per-document Insert loop without having to manage *mgo.Bulk
+// state themselves. Buffered operations are only replayed onto a *mgo.Bulk
+// fetched fresh from db at Flush time, rather than one built once at
+// construction, so a long-running import survives a health-check reconnect
+// or certificate rotation partway through.
+type BufferedBulk struct {
+	db     *DB
+	coll   string
+	size   int
+	ops    []bulkWriteOp
+	result mgo.BulkResult
+}
+
+type bulkWriteOp struct {
+	insert        bool
+	upsert        bool
+	selector, doc interface{}
+}
+
+// BufferedBulk creates a BufferedBulk writer over collection that flushes
+// automatically every size operations. Call Flush when done to send any
+// remaining buffered operations.
+func (db *DB) BufferedBulk(collection string, size int) *BufferedBulk {
+	return &BufferedBulk{
+		db:   db,
+		coll: collection,
+		size: size,
+	}
+}
+
+// Insert buffers a document for insertion.
+func (b *BufferedBulk) Insert(doc interface{}) error {
+	b.ops = append(b.ops, bulkWriteOp{insert: true, doc: doc})
+	return b.maybeFlush()
+}
+
+// Upsert buffers an upsert of doc matching selector.
+func (b *BufferedBulk) Upsert(selector, doc interface{}) error {
+	b.ops = append(b.ops, bulkWriteOp{upsert: true, selector: selector, doc: doc})
+	return b.maybeFlush()
+}
+
+// Remove buffers removal of the first document matching selector.
+func (b *BufferedBulk) Remove(selector interface{}) error {
+	b.ops = append(b.ops, bulkWriteOp{selector: selector})
+	return b.maybeFlush()
+}
+
+func (b *BufferedBulk) maybeFlush() error {
+	if len(b.ops) < b.size {
+		return nil
+	}
+	return b.Flush()
+}
+
+// Flush runs any buffered operations now, instead of waiting for the
+// buffer to fill. It must also be called after the last Insert/Upsert/
+// Remove to send a partially filled buffer.
+//
+// Flush fetches the collection from db immediately before running the
+// batch, so it always runs against the current session even if db
+// reconnected or rotated certificates since the previous Flush.
+//
+// mgo.Bulk.Run returns a nil *mgo.BulkResult whenever any operation in the
+// batch failed, even if earlier operations in that same batch succeeded, so
+// a failed batch does not contribute to Result - Flush only returns the
+// error in that case. Successful batches are aggregated as usual.
+func (b *BufferedBulk) Flush() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	bulk := b.db.C(b.coll).Bulk()
+	for _, op := range b.ops {
+		switch {
+		case op.insert:
+			bulk.Insert(op.doc)
+		case op.upsert:
+			bulk.Upsert(op.selector, op.doc)
+		default:
+			bulk.Remove(op.selector)
+		}
+	}
+	res, err := bulk.Run()
+	b.ops = b.ops[:0]
+	if err != nil {
+		return errors.Wrap(err, "running buffered bulk operation")
+	}
+	b.result.Matched += res.Matched
+	b.result.Modified += res.Modified
+	return nil
+}
+
+// Result returns the *mgo.BulkResult aggregated across all batches flushed
+// so far that completed without error. A batch that failed partway through
+// contributes nothing to it, since mgo.Bulk.Run does not report counts for
+// a failed batch.
+func (b *BufferedBulk) Result() *mgo.BulkResult {
+	return &b.result
+}
@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/tls"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// WatchCertificates watches the CA and client certificate files TLSOptions
+// was loaded from (via TLSOptionsFromFiles) and, on change, rebuilds the
+// TLS config, re-parses the x509 subject, and re-dials with fresh
+// credentials, atomically swapping the underlying session. It runs until
+// ctx is canceled. Certificates not loaded from files cannot be watched.
+func (db *DB) WatchCertificates(ctx context.Context) error {
+	if db.tlsOpts.ClientCertFile == "" {
+		return errors.New("WatchCertificates requires TLSOptions loaded via TLSOptionsFromFiles")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "creating certificate watcher")
+	}
+	for _, f := range []string{db.tlsOpts.CACertFile, db.tlsOpts.ClientCertFile} {
+		if f == "" {
+			continue
+		}
+		if err := watcher.Add(f); err != nil {
+			watcher.Close()
+			return errors.Wrap(err, "watching certificate file")
+		}
+	}
+
+	if err := db.reloadCertificates(); err != nil {
+		watcher.Close()
+		return errors.Wrap(err, "installing certificate watcher")
+	}
+
+	go db.watchCertificates(ctx, watcher)
+	return nil
+}
+
+func (db *DB) watchCertificates(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			db.reloadCertificates()
+		case <-watcher.Errors:
+		}
+	}
+}
+
+// reloadCertificates re-reads the watched certificate files, re-dials and
+// swaps the live session under db.mu. New TLS handshakes on the already
+// established session pick up the rotated certificate via
+// TLSOptions.getClientCertificate without waiting for the re-dial.
+func (db *DB) reloadCertificates() error {
+	current := db.tlsOptions()
+	fresh, err := TLSOptionsFromFiles(current.CACertFile, current.ClientCertFile)
+	if err != nil {
+		return err
+	}
+	fresh.InsecureSkipVerify = current.InsecureSkipVerify
+	fresh.ServerName = current.ServerName
+	fresh.SystemCAs = current.SystemCAs
+	fresh.getClientCertificate = db.currentClientCertificate
+
+	_, clientCert, err := fresh.Config()
+	if err != nil {
+		return errors.Wrap(err, "rebuilding TLS config")
+	}
+	if clientCert.Leaf != nil {
+		db.clientCert.Store(&clientCert)
+	}
+
+	mongoSession, mongoInfo, err := dial(db.dialURI, fresh, db.dialOpts)
+	if err != nil {
+		return errors.Wrap(err, "re-dialing with rotated certificates")
+	}
+
+	db.mu.Lock()
+	old := db.mgoDB.Session
+	db.tlsOpts = fresh
+	db.mgoDB = mongoSession.DB(mongoInfo.Database)
+	db.mu.Unlock()
+	old.Close()
+
+	atomic.StoreInt32(&db.healthy, 1)
+	db.fireReconnect()
+	return nil
+}
+
+func (db *DB) currentClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, _ := db.clientCert.Load().(*tls.Certificate)
+	if cert == nil {
+		return nil, errors.New("no client certificate loaded")
+	}
+	return cert, nil
+}
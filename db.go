@@ -6,11 +6,12 @@
 // import "github.com/go-mixins/mongodb"
 //
 //
-// db, err := db.New("mongodb:///database", "", "") // No TLS and x509 auth
+// db, err := mongodb.New("mongodb:///database", mongodb.TLSOptions{}) // No TLS and x509 auth
 //
 // or
 //
-// db, err := db.New("mongodb:///database", "/etc/ssl/certs/CA.crt", "/etc/ssl/certs/mongoClient.pem")
+// tlsOpts, err := mongodb.TLSOptionsFromFiles("/etc/ssl/certs/CA.crt", "/etc/ssl/certs/mongoClient.pem")
+// db, err := mongodb.New("mongodb:///database", tlsOpts)
 //
 // defer db.Close()
 // ...
@@ -25,102 +26,176 @@ package mongodb
 
 import (
 	"crypto/tls"
-	"crypto/x509"
-	"fmt"
-	"io/ioutil"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/mgo.v2"
 )
 
-func getUsername(cert *x509.Certificate) string {
-	var email string
-	for _, i := range cert.Subject.Names {
-		if i.Type.String() == "1.2.840.113549.1.9.1" {
-			email = i.Value.(string)
-			break
-		}
-	}
-	return fmt.Sprintf(
-		"emailAddress=%s,CN=%s,OU=%s,O=%s,L=%s,C=%s",
-		email,
-		cert.Subject.CommonName,
-		cert.Subject.OrganizationalUnit[0],
-		cert.Subject.Organization[0],
-		cert.Subject.Locality[0],
-		cert.Subject.Country[0],
-	)
-}
-
 // ConnectTimeout used to limit database connection time
 var ConnectTimeout = 10 * time.Second
 
+// DefaultSocketTimeout bounds how long a single socket operation may take.
+// It is generous enough to survive heavyweight queries without leaving
+// sockets open indefinitely.
+var DefaultSocketTimeout = time.Minute
+
+// DialOpts controls session-level behavior established right after dialing.
+// The zero value reproduces the historical behavior of New: a
+// mgo.Monotonic consistency mode and a "majority" write concern.
+type DialOpts struct {
+	// Timeout bounds the initial connection to the server. Defaults to
+	// ConnectTimeout.
+	Timeout time.Duration
+	// SocketTimeout bounds individual socket operations. Defaults to
+	// DefaultSocketTimeout.
+	SocketTimeout time.Duration
+	// SyncTimeout bounds how long operations wait for a cluster sync to
+	// complete. Left unset (zero) to use mgo's own default.
+	SyncTimeout time.Duration
+	// ConsistencyMode is passed to Session.SetMode. A nil value defaults
+	// to mgo.Monotonic; use a pointer to mgo.Eventual, mgo.Monotonic or
+	// mgo.Strong to pick one explicitly. A plain mgo.Mode field could not
+	// tell "left unset" apart from an explicit mgo.Eventual, since both
+	// are the zero value.
+	ConsistencyMode *mgo.Mode
+	// ReadPreference, when non-nil, overrides ConsistencyMode for read
+	// operations. mgo conflates read preference and consistency into a
+	// single Mode, so in practice this simply replaces ConsistencyMode;
+	// the separate field exists so callers can reason about reads and
+	// consistency independently, as with the driver/ package.
+	ReadPreference *mgo.Mode
+	// WriteConcern is passed to Session.SetSafe. Defaults to
+	// &mgo.Safe{WMode: "majority"}.
+	WriteConcern *mgo.Safe
+	// Direct, if set, dials a single server instead of discovering and
+	// monitoring the whole replica set.
+	Direct bool
+	// Auth, when Mechanism is non-empty, is used to log in instead of the
+	// x509 client certificate login. See the Auth type for supported
+	// mechanisms.
+	Auth Auth
+}
+
 // DB wraps mgo.Database functionality for ease of use
 type DB struct {
-	*mgo.Database
+	// mgoDB is unexported, rather than embedded, so every access - even
+	// Session and Name - goes through the RLock-guarded database() accessor
+	// instead of racing the db.mu.Lock() swap in reconnect()/
+	// reloadCertificates().
+	mgoDB *mgo.Database
+
+	// mu guards swapping mgoDB for a freshly dialed one, from the health
+	// checker or WatchCertificates.
+	mu sync.RWMutex
+
+	// dialURI, tlsOpts and dialOpts are retained so the health checker and
+	// WatchCertificates can fully re-dial after a failover, network blip
+	// or certificate rotation.
+	dialURI  string
+	tlsOpts  TLSOptions
+	dialOpts DialOpts
+
+	// clientCert holds the *tls.Certificate currently presented for x509
+	// login, as a target for TLSOptions.getClientCertificate.
+	clientCert atomic.Value
+
+	healthMu       sync.Mutex
+	healthStop     chan struct{}
+	healthy        int32
+	reconnectHooks []func()
 }
 
 // New creates new MongoDB connection with optional TLS connection and
-// authentication. If caCertFile is non-empty, it specifies server CA
-// certificate for server verification and implies TLS connection. If
-// pemKeyFile is non-empty it will be presented for client authentication and
-// login.
-func New(uri, caCertFile, pemKeyFile string) (db *DB, err error) {
+// authentication. See TLSOptions for the available TLS and x509 login
+// controls; the zero TLSOptions{} dials without TLS.
+func New(uri string, tlsOpts TLSOptions) (*DB, error) {
+	return NewWithOpts(uri, tlsOpts, DialOpts{})
+}
+
+// NewWithOpts is the same as New but additionally accepts a DialOpts value
+// to tune timeouts, consistency and write concern per deployment. Passing
+// the zero DialOpts{} reproduces the behavior of New.
+func NewWithOpts(uri string, tlsOpts TLSOptions, dialOpts DialOpts) (db *DB, err error) {
 	var (
-		mongo        *mgo.DialInfo
 		mongoSession *mgo.Session
-		clientCert   tls.Certificate
+		mongoInfo    *mgo.DialInfo
 	)
+	if mongoSession, mongoInfo, err = dial(uri, tlsOpts, dialOpts); err != nil {
+		return
+	}
+	db = &DB{
+		mgoDB:    mongoSession.DB(mongoInfo.Database),
+		dialURI:  uri,
+		tlsOpts:  tlsOpts,
+		dialOpts: dialOpts,
+		healthy:  1,
+	}
+	return
+}
 
-	if mongo, err = mgo.ParseURL(uri); err != nil {
+// dial parses uri, establishes the TLS/plain connection described by
+// tlsOpts, applies dialOpts and logs in, returning the resulting session
+// and the parsed dial info (needed for the database name).
+func dial(uri string, tlsOpts TLSOptions, dialOpts DialOpts) (mongoSession *mgo.Session, mongo *mgo.DialInfo, err error) {
+	var clientCert tls.Certificate
+
+	if dialOpts.Timeout == 0 {
+		dialOpts.Timeout = ConnectTimeout
+	}
+	if dialOpts.SocketTimeout == 0 {
+		dialOpts.SocketTimeout = DefaultSocketTimeout
+	}
+	if dialOpts.WriteConcern == nil {
+		dialOpts.WriteConcern = &mgo.Safe{WMode: "majority"}
+	}
+	if (dialOpts.Auth.Mechanism == "GSSAPI" || dialOpts.Auth.Mechanism == "SCRAM-SHA-256") && !gssapiEnabled {
+		err = errors.New(dialOpts.Auth.Mechanism + " support is not compiled in, rebuild with -tags mongodb_gssapi,sasl")
 		return
 	}
 
-	if pemKeyFile != "" {
-		if caCertFile == "" {
-			err = errors.New("pemKeyFile specified without caCertFile")
-			return
-		}
-		if clientCert, err = tls.LoadX509KeyPair(pemKeyFile, pemKeyFile); err != nil {
-			err = errors.Wrap(err, "loading CA certificate")
-			return
-		}
-		if clientCert.Leaf, err = x509.ParseCertificate(clientCert.Certificate[0]); err != nil {
-			err = errors.Wrap(err, "parsing CA certificate")
-			return
-		}
+	if mongo, err = mgo.ParseURL(uri); err != nil {
+		return
 	}
+	mongo.Direct = dialOpts.Direct
 
-	if caCertFile != "" {
-		var rootPEM []byte
-		if rootPEM, err = ioutil.ReadFile(caCertFile); err != nil {
-			err = errors.Wrap(err, "loading client certificate")
-			return
-		}
-		tlsCfg := &tls.Config{
-			RootCAs: x509.NewCertPool(),
-		}
-		if !tlsCfg.RootCAs.AppendCertsFromPEM(rootPEM) {
-			err = errors.Wrap(err, "parsing client certificate")
-			return
-		}
-		if clientCert.Leaf != nil {
-			tlsCfg.Certificates = append(tlsCfg.Certificates, clientCert)
-		}
+	var tlsCfg *tls.Config
+	if tlsCfg, clientCert, err = tlsOpts.Config(); err != nil {
+		return
+	}
+	if tlsCfg != nil {
 		mongo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
 			return tls.Dial("tcp", addr.String(), tlsCfg)
 		}
 	}
-	mongo.Timeout = ConnectTimeout
+	mongo.Timeout = dialOpts.Timeout
 	if mongoSession, err = mgo.DialWithInfo(mongo); err != nil {
 		err = errors.Wrap(err, "connecting")
 		return
 	}
-	mongoSession.SetMode(mgo.Monotonic, true)
-	mongoSession.SetSafe(&mgo.Safe{WMode: "majority"})
-	if clientCert.Leaf != nil {
+	mongoSession.SetSocketTimeout(dialOpts.SocketTimeout)
+	if dialOpts.SyncTimeout != 0 {
+		mongoSession.SetSyncTimeout(dialOpts.SyncTimeout)
+	}
+	consistencyMode := mgo.Monotonic
+	if dialOpts.ConsistencyMode != nil {
+		consistencyMode = *dialOpts.ConsistencyMode
+	}
+	if dialOpts.ReadPreference != nil {
+		consistencyMode = *dialOpts.ReadPreference
+	}
+	mongoSession.SetMode(consistencyMode, true)
+	mongoSession.SetSafe(dialOpts.WriteConcern)
+	switch {
+	case dialOpts.Auth.Mechanism != "":
+		if err = mongoSession.Login(dialOpts.Auth.credential()); err != nil {
+			err = errors.Wrap(err, "authenticating with "+dialOpts.Auth.Mechanism)
+			return
+		}
+	case clientCert.Leaf != nil:
 		if err = mongoSession.Login(&mgo.Credential{
 			Mechanism: "MONGODB-X509",
 			Source:    "$external",
@@ -130,24 +205,52 @@ func New(uri, caCertFile, pemKeyFile string) (db *DB, err error) {
 			return
 		}
 	}
-	db = &DB{mongoSession.DB(mongo.Database)}
 	return
 }
 
+// database returns the live *mgo.Database under db.mu, so it reflects
+// whichever session StartHealthCheck/WatchCertificates last swapped in.
+// All access to the underlying session, including its Session and Name,
+// goes through this accessor rather than a promoted embedded field, since
+// mgoDB is unexported and not embedded.
+func (db *DB) database() *mgo.Database {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.mgoDB
+}
+
+// tlsOptions returns the TLSOptions currently in effect, which
+// WatchCertificates updates on every certificate rotation.
+func (db *DB) tlsOptions() TLSOptions {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.tlsOpts
+}
+
+// C returns the named collection on the current session. There is no
+// promoted mgo.Database field to call this on directly, since mgoDB is
+// unexported - this keeps every call safe while StartHealthCheck or
+// WatchCertificates are running.
+func (db *DB) C(name string) *mgo.Collection {
+	return db.database().C(name)
+}
+
 // Close closes underlying Session
 func (db *DB) Close() {
-	db.Session.Close()
+	db.database().Session.Close()
 }
 
 // Clone clones the database session and returns a new initialized DB object
 func (db *DB) Clone() *DB {
-	return &DB{db.Session.Clone().DB(db.Name)}
+	d := db.database()
+	return &DB{mgoDB: d.Session.Clone().DB(d.Name)}
 }
 
 // Copy is the same as Clone, but copies the underlying session. See mgo
 // documentation for explanation.
 func (db *DB) Copy() *DB {
-	return &DB{db.Session.Copy().DB(db.Name)}
+	d := db.database()
+	return &DB{mgoDB: d.Session.Copy().DB(d.Name)}
 }
 
 // EnsureIndexes creates indices specified as a map from collection name to
@@ -0,0 +1,130 @@
+package mongodb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHealthCheckInterval is the default interval between Ping checks.
+var DefaultHealthCheckInterval = 2 * time.Second
+
+// DefaultHealthCheckRetries is the default number of consecutive failed
+// pings tolerated before a reconnect is attempted.
+var DefaultHealthCheckRetries = 3
+
+// HealthCheckOpts configures StartHealthCheck. The zero value uses
+// DefaultHealthCheckInterval and DefaultHealthCheckRetries.
+type HealthCheckOpts struct {
+	Interval time.Duration
+	Retries  int
+}
+
+// StartHealthCheck launches a background goroutine that periodically pings
+// the server. After Retries consecutive failed pings it attempts
+// Session.Refresh and, failing that, a full re-dial using the parameters
+// passed to New/NewWithOpts, with the TLS options WatchCertificates last
+// installed in place of the original ones. Calling StartHealthCheck again
+// replaces any previously running checker.
+func (db *DB) StartHealthCheck(opts HealthCheckOpts) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultHealthCheckInterval
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = DefaultHealthCheckRetries
+	}
+
+	db.healthMu.Lock()
+	if db.healthStop != nil {
+		close(db.healthStop)
+	}
+	stop := make(chan struct{})
+	db.healthStop = stop
+	db.healthMu.Unlock()
+
+	go db.runHealthCheck(opts, stop)
+}
+
+// StopHealthCheck stops a previously started health checker. It is a no-op
+// if none is running.
+func (db *DB) StopHealthCheck() {
+	db.healthMu.Lock()
+	defer db.healthMu.Unlock()
+	if db.healthStop != nil {
+		close(db.healthStop)
+		db.healthStop = nil
+	}
+}
+
+// Healthy reports whether the last health check (or the initial dial)
+// succeeded.
+func (db *DB) Healthy() bool {
+	return atomic.LoadInt32(&db.healthy) == 1
+}
+
+// OnReconnect registers a hook called after a successful reconnect, so
+// callers can re-register change streams or tailable cursors.
+func (db *DB) OnReconnect(fn func()) {
+	db.healthMu.Lock()
+	db.reconnectHooks = append(db.reconnectHooks, fn)
+	db.healthMu.Unlock()
+}
+
+func (db *DB) runHealthCheck(opts HealthCheckOpts, stop chan struct{}) {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			db.checkHealth(opts)
+		}
+	}
+}
+
+func (db *DB) checkHealth(opts HealthCheckOpts) {
+	session := db.database().Session
+
+	var err error
+	for i := 0; i < opts.Retries; i++ {
+		if err = session.Ping(); err == nil {
+			atomic.StoreInt32(&db.healthy, 1)
+			return
+		}
+	}
+
+	atomic.StoreInt32(&db.healthy, 0)
+	session.Refresh()
+	if session.Ping() == nil {
+		atomic.StoreInt32(&db.healthy, 1)
+		db.fireReconnect()
+		return
+	}
+
+	db.reconnect()
+}
+
+func (db *DB) reconnect() {
+	mongoSession, mongoInfo, err := dial(db.dialURI, db.tlsOptions(), db.dialOpts)
+	if err != nil {
+		return
+	}
+
+	db.mu.Lock()
+	old := db.mgoDB.Session
+	db.mgoDB = mongoSession.DB(mongoInfo.Database)
+	db.mu.Unlock()
+	old.Close()
+
+	atomic.StoreInt32(&db.healthy, 1)
+	db.fireReconnect()
+}
+
+func (db *DB) fireReconnect() {
+	db.healthMu.Lock()
+	hooks := append([]func(){}, db.reconnectHooks...)
+	db.healthMu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
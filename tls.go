@@ -0,0 +1,153 @@
+package mongodb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// TLSOptions controls the TLS connection and x509 client authentication
+// used by New and NewWithOpts. The zero value dials without TLS.
+type TLSOptions struct {
+	// InsecureSkipVerify disables server certificate verification. Only
+	// useful against self-signed dev clusters; never set in production.
+	InsecureSkipVerify bool
+	// ServerName overrides the name used for server certificate
+	// verification and SNI, for deployments where it differs from the
+	// dialed host (e.g. SNI-fronted endpoints).
+	ServerName string
+	// CACertPEM, if non-empty, is the PEM-encoded CA certificate used to
+	// verify the server. Implies a TLS connection.
+	CACertPEM []byte
+	// ClientCertPEM and ClientKeyPEM, if non-empty, are presented for
+	// client authentication and x509 login.
+	ClientCertPEM []byte
+	ClientKeyPEM  []byte
+	// SystemCAs, if set, seeds the root CA pool from
+	// x509.SystemCertPool() instead of an empty pool, so CACertPEM is
+	// appended to the system roots rather than replacing them.
+	SystemCAs bool
+
+	// CACertFile and ClientCertFile record the paths CACertPEM and
+	// ClientCertPEM/ClientKeyPEM were loaded from, when TLSOptions was
+	// built via TLSOptionsFromFiles. They are only used by
+	// DB.WatchCertificates to detect certificate rotations; options built
+	// by hand (e.g. from Vault) can leave them empty.
+	CACertFile     string
+	ClientCertFile string
+
+	// getClientCertificate, when set, is installed as tls.Config's
+	// GetClientCertificate instead of a static Certificates slice, so
+	// WatchCertificates can rotate the presented certificate without
+	// dropping already-pooled connections.
+	getClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+}
+
+func (o TLSOptions) enabled() bool {
+	return o.InsecureSkipVerify || o.SystemCAs || o.ServerName != "" || len(o.CACertPEM) > 0 || len(o.ClientCertPEM) > 0
+}
+
+// Config builds the tls.Config and, if a client certificate was supplied,
+// the parsed client certificate used for x509 login. It returns a nil
+// *tls.Config when TLS was not requested. It is exported so alternate
+// backends, such as the mongo-go-driver-based driver package, can reuse it.
+func (o TLSOptions) Config() (tlsCfg *tls.Config, clientCert tls.Certificate, err error) {
+	if len(o.ClientCertPEM) > 0 {
+		if clientCert, err = tls.X509KeyPair(o.ClientCertPEM, o.ClientKeyPEM); err != nil {
+			err = errors.Wrap(err, "loading client certificate")
+			return
+		}
+		if clientCert.Leaf, err = x509.ParseCertificate(clientCert.Certificate[0]); err != nil {
+			err = errors.Wrap(err, "parsing client certificate")
+			return
+		}
+	}
+
+	if !o.enabled() {
+		return
+	}
+
+	tlsCfg = &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		ServerName:         o.ServerName,
+	}
+	if o.SystemCAs {
+		if tlsCfg.RootCAs, err = x509.SystemCertPool(); err != nil {
+			err = errors.Wrap(err, "loading system CA pool")
+			return
+		}
+		if tlsCfg.RootCAs == nil {
+			tlsCfg.RootCAs = x509.NewCertPool()
+		}
+	} else {
+		tlsCfg.RootCAs = x509.NewCertPool()
+	}
+	if len(o.CACertPEM) > 0 {
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(o.CACertPEM) {
+			err = errors.New("parsing CA certificate")
+			return
+		}
+	}
+	if clientCert.Leaf != nil {
+		if o.getClientCertificate != nil {
+			tlsCfg.GetClientCertificate = o.getClientCertificate
+		} else {
+			tlsCfg.Certificates = append(tlsCfg.Certificates, clientCert)
+		}
+	}
+	return
+}
+
+// TLSOptionsFromFiles is a convenience constructor for the common case of
+// loading the CA certificate and combined client cert/key from files, as
+// opposed to populating TLSOptions from Vault, env vars, or similar.
+func TLSOptionsFromFiles(caCertFile, pemKeyFile string) (opts TLSOptions, err error) {
+	if caCertFile != "" {
+		if opts.CACertPEM, err = ioutil.ReadFile(caCertFile); err != nil {
+			err = errors.Wrap(err, "loading CA certificate")
+			return
+		}
+		opts.CACertFile = caCertFile
+	}
+	if pemKeyFile != "" {
+		var pemData []byte
+		if pemData, err = ioutil.ReadFile(pemKeyFile); err != nil {
+			err = errors.Wrap(err, "loading client certificate")
+			return
+		}
+		opts.ClientCertPEM = pemData
+		opts.ClientKeyPEM = pemData
+		opts.ClientCertFile = pemKeyFile
+	}
+	return
+}
+
+// X509Username derives the MONGODB-X509 login username from a client
+// certificate's subject, in the RFC 2253-like form the server expects. It
+// is exported so alternate backends, such as the mongo-go-driver-based
+// driver package, can reuse it without re-implementing subject parsing.
+func X509Username(cert *x509.Certificate) string {
+	return getUsername(cert)
+}
+
+func getUsername(cert *x509.Certificate) string {
+	var email string
+	for _, i := range cert.Subject.Names {
+		if i.Type.String() == "1.2.840.113549.1.9.1" {
+			email = i.Value.(string)
+			break
+		}
+	}
+	return fmt.Sprintf(
+		"emailAddress=%s,CN=%s,OU=%s,O=%s,L=%s,C=%s",
+		email,
+		cert.Subject.CommonName,
+		cert.Subject.OrganizationalUnit[0],
+		cert.Subject.Organization[0],
+		cert.Subject.Locality[0],
+		cert.Subject.Country[0],
+	)
+}